@@ -1,22 +1,265 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-// StatelessHTTPContextFunc is a function that takes an existing context and returns
-// a potentially modified context.
-// This can be used to inject context values from environment variables,
-// for example.
-type StatelessHTTPContextFunc func(ctx context.Context) context.Context
+// defaultCompressionThreshold is the minimum response size, in bytes, that
+// StatelessHTTPServer will compress when the client supports it.
+const defaultCompressionThreshold = 1024
+
+// maxJWTClockSkew is the allowed drift between the server's clock and the
+// "iat" claim of an incoming bearer token.
+const maxJWTClockSkew = 60 * time.Second
+
+// RequestTrace describes a single JSON-RPC message processed by
+// StatelessHTTPServer, passed to a Tracer's TraceRequest method once per
+// message (or once per batch entry).
+type RequestTrace struct {
+	// Method is the JSON-RPC method name, empty if Request could not be
+	// parsed far enough to recover it.
+	Method string
+	// ID is the JSON-RPC request id, nil for notifications.
+	ID any
+	// Request and Response are the raw JSON bodies exchanged for this
+	// message. Response is nil for notifications.
+	Request  json.RawMessage
+	Response json.RawMessage
+	// StatusCode is the HTTP status written for this message (for a batch
+	// entry, the status it would have received standalone).
+	StatusCode int
+	Duration   time.Duration
+	RemoteAddr string
+	// Err is set if the response to the message could not be marshaled.
+	Err error
+}
+
+// Tracer receives one RequestTrace per JSON-RPC message processed by
+// StatelessHTTPServer. Implementations must be safe for concurrent use, since
+// batch entries are traced from multiple goroutines. To integrate with
+// OpenTelemetry, implement Tracer and start a span (or record a metric) from
+// TraceRequest using info.Duration, info.Err and info.StatusCode.
+type Tracer interface {
+	TraceRequest(info RequestTrace)
+}
+
+// logTracer is the Tracer returned by NewLogTracer.
+type logTracer struct {
+	logger *log.Logger
+}
+
+// NewLogTracer returns a Tracer that writes one line per traced request to
+// logger.
+func NewLogTracer(logger *log.Logger) Tracer {
+	return &logTracer{logger: logger}
+}
+
+func (t *logTracer) TraceRequest(info RequestTrace) {
+	t.logger.Printf(
+		"method=%s id=%v status=%d duration=%s remote=%s err=%v",
+		info.Method, info.ID, info.StatusCode, info.Duration, info.RemoteAddr, info.Err,
+	)
+}
+
+// jsonrpcEnvelope recovers just enough of a JSON-RPC message to populate a
+// RequestTrace, without depending on the concrete mcp message types.
+type jsonrpcEnvelope struct {
+	Method string `json:"method"`
+	ID     any    `json:"id"`
+}
+
+// defaultBatchWorkers is the default value of batchWorkers, used when no
+// WithStatelessHTTPBatchWorkers option is given.
+const defaultBatchWorkers = 10
+
+// defaultHeartbeatInterval is how often a heartbeat comment is written to an
+// open SSE stream when no WithStatelessHTTPHeartbeatInterval option is given.
+const defaultHeartbeatInterval = 15 * time.Second
+
+// mcpSessionIDHeader carries the stream ID that ties a GET SSE connection to
+// the POST requests whose server-initiated notifications it should receive,
+// and that a reconnecting client echoes back (together with Last-Event-ID)
+// to resume the same logical stream instead of starting a new one.
+const mcpSessionIDHeader = "Mcp-Session-Id"
+
+// EventStore persists SSE events emitted on a stream so that a client that
+// reconnects with a Last-Event-ID header can replay what it missed.
+// The default, in-memory implementation is sufficient for a single server
+// instance; a distributed deployment should supply its own.
+type EventStore interface {
+	// Append records data as the next event on streamID and returns the ID
+	// assigned to it.
+	Append(streamID string, data []byte) (eventID string, err error)
+	// Replay invokes send, in order, for every event recorded on streamID
+	// after lastEventID. If lastEventID is empty, Replay is a no-op.
+	Replay(streamID string, lastEventID string, send func(eventID string, data []byte) error) error
+}
+
+// defaultEventHistorySize bounds how many events the in-memory EventStore
+// retains per stream.
+const defaultEventHistorySize = 1000
+
+// defaultMaxEventStreams bounds how many distinct streams the in-memory
+// EventStore retains history for. Once exceeded, the oldest stream (by first
+// Append) is dropped, so a long-running server with many short-lived
+// connections does not grow the store without bound.
+const defaultMaxEventStreams = 10000
+
+type memoryEvent struct {
+	id   string
+	data []byte
+}
+
+// memoryEventStore is the default in-memory EventStore used by
+// StatelessHTTPServer. Event history does not survive a process restart.
+type memoryEventStore struct {
+	mu     sync.Mutex
+	events map[string][]memoryEvent
+	// streamOrder tracks streams in the order they were first seen, so the
+	// oldest can be evicted once maxStreams is exceeded.
+	streamOrder []string
+	maxStreams  int
+}
+
+func newMemoryEventStore() *memoryEventStore {
+	return &memoryEventStore{
+		events:     make(map[string][]memoryEvent),
+		maxStreams: defaultMaxEventStreams,
+	}
+}
+
+func (m *memoryEventStore) Append(streamID string, data []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events, seen := m.events[streamID]
+	if !seen {
+		m.streamOrder = append(m.streamOrder, streamID)
+		m.evictOldestLocked()
+	}
+
+	// Number from the last retained event rather than len(events), so IDs
+	// keep increasing after older history has been trimmed.
+	next := 1
+	if len(events) > 0 {
+		var last int
+		fmt.Sscan(events[len(events)-1].id, &last)
+		next = last + 1
+	}
+	id := fmt.Sprintf("%d", next)
+	events = append(events, memoryEvent{id: id, data: data})
+	if len(events) > defaultEventHistorySize {
+		events = events[len(events)-defaultEventHistorySize:]
+	}
+	m.events[streamID] = events
+	return id, nil
+}
+
+// evictOldestLocked drops the oldest streams once the number of tracked
+// streams exceeds m.maxStreams. m.mu must be held.
+func (m *memoryEventStore) evictOldestLocked() {
+	for len(m.streamOrder) > m.maxStreams {
+		oldest := m.streamOrder[0]
+		m.streamOrder = m.streamOrder[1:]
+		delete(m.events, oldest)
+	}
+}
+
+func (m *memoryEventStore) Replay(streamID, lastEventID string, send func(string, []byte) error) error {
+	if lastEventID == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	events := append([]memoryEvent(nil), m.events[streamID]...)
+	m.mu.Unlock()
+
+	found := false
+	for _, evt := range events {
+		if !found {
+			if evt.id == lastEventID {
+				found = true
+			}
+			continue
+		}
+		if err := send(evt.id, evt.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statelessSSESession is the ClientSession registered with the wrapped
+// MCPServer for the lifetime of a single SSE connection, so that
+// server-initiated notifications addressed to it can be streamed back to the
+// client.
+type statelessSSESession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+}
+
+func newStatelessSSESession(id string) *statelessSSESession {
+	return &statelessSSESession{
+		id:            id,
+		notifications: make(chan mcp.JSONRPCNotification, 100),
+	}
+}
+
+func (s *statelessSSESession) SessionID() string { return s.id }
+
+func (s *statelessSSESession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *statelessSSESession) Initialize() { s.initialized.Store(true) }
+
+func (s *statelessSSESession) Initialized() bool { return s.initialized.Load() }
+
+// generateSessionID returns a random identifier used as both the SSE stream
+// ID and the registered ClientSession ID for a single connection.
+func generateSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// StatelessHTTPContextFunc is a function that takes an existing context and
+// the incoming request, and returns a potentially modified context. It is
+// called once per request, before that request's message(s) are dispatched to
+// the wrapped MCPServer. This can be used to inject request-scoped values,
+// such as an auth principal, trace ID, or tenant ID derived from a header.
+type StatelessHTTPContextFunc func(ctx context.Context, r *http.Request) context.Context
+
+// StatelessHTTPContextFuncLegacy is the pre-per-request signature of
+// StatelessHTTPContextFunc.
+//
+// Deprecated: use StatelessHTTPContextFunc instead, which is invoked once per
+// request (with access to the request) rather than once per server instance.
+type StatelessHTTPContextFuncLegacy func(ctx context.Context) context.Context
 
 // StatelessHTTPServer wraps a MCPServer and handles StatelessHTTP communication.
 // It provides a simple way to create command-line MCP servers that
@@ -27,6 +270,50 @@ type StatelessHTTPServer struct {
 	errLogger   *log.Logger
 	contextFunc StatelessHTTPContextFunc
 
+	// batchLimit caps the number of messages accepted in a single JSON-RPC
+	// batch request. Zero means unlimited.
+	batchLimit int
+	// batchWorkers bounds how many messages in a JSON-RPC batch are
+	// dispatched to the wrapped MCPServer concurrently.
+	batchWorkers int
+
+	// eventStore backs resumable SSE streams opened via GET.
+	eventStore EventStore
+	// heartbeatInterval is how often a heartbeat comment is written to open
+	// SSE streams. Zero disables heartbeats.
+	heartbeatInterval time.Duration
+	// sseSessions maps a live SSE stream's Mcp-Session-Id to its
+	// *statelessSSESession, so a concurrent POST carrying the same header can
+	// have its server-initiated notifications routed to that stream.
+	sseSessions sync.Map
+
+	// middlewares wrap every request, outermost first, before it reaches the
+	// base path router.
+	middlewares []func(http.Handler) http.Handler
+	// jwtSecret, if set, requires every request to carry a valid HS256
+	// bearer JWT in the Authorization header.
+	jwtSecret []byte
+	// corsOrigins, if set, enables CORS responses for the listed origins.
+	// "*" allows any origin.
+	corsOrigins []string
+	// allowedHosts, if set, restricts requests to the listed Host header
+	// values (vhosts). "*" allows any host.
+	allowedHosts []string
+
+	// tracer, if set, receives one RequestTrace per JSON-RPC message
+	// processed.
+	tracer Tracer
+
+	// compressionEnabled turns gzip/deflate response compression on or off.
+	compressionEnabled bool
+	// compressionThreshold is the minimum response size, in bytes, that will
+	// be compressed.
+	compressionThreshold int
+
+	// handler is the middleware-wrapped request handler, built once the
+	// constructor's options have been applied.
+	handler http.Handler
+
 	srv *http.Server
 
 	mu sync.RWMutex
@@ -35,21 +322,134 @@ type StatelessHTTPServer struct {
 // StatelessHTTPOption defines a function type for configuring StatelessHTTPServer
 type StatelessHTTPOption func(*StatelessHTTPServer)
 
-// WithContextFunc sets a function that will be called to customise the context
-// to the server. Note that the StatelessHTTP server uses the same context for all requests,
-// so this function will only be called once per server instance.
+// WithStatelessHTTPContextFunc sets a function that is called once per
+// request, with that request, to customise the context passed to the wrapped
+// MCPServer.
 func WithStatelessHTTPContextFunc(fn StatelessHTTPContextFunc) StatelessHTTPOption {
 	return func(s *StatelessHTTPServer) {
 		s.contextFunc = fn
 	}
 }
 
+// WithStatelessHTTPContextFuncLegacy sets a StatelessHTTPContextFuncLegacy.
+//
+// Deprecated: use WithStatelessHTTPContextFunc instead.
+func WithStatelessHTTPContextFuncLegacy(fn StatelessHTTPContextFuncLegacy) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.contextFunc = func(ctx context.Context, _ *http.Request) context.Context {
+			return fn(ctx)
+		}
+	}
+}
+
 func WithHTTPBasePath(basePath string) StatelessHTTPOption {
 	return func(s *StatelessHTTPServer) {
 		s.basePath = basePath
 	}
 }
 
+// WithStatelessHTTPBatchLimit sets the maximum number of messages accepted in
+// a single JSON-RPC batch request. Batches larger than n are rejected with a
+// JSON-RPC error. A limit of 0 (the default) means unlimited.
+func WithStatelessHTTPBatchLimit(n int) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.batchLimit = n
+	}
+}
+
+// WithStatelessHTTPBatchWorkers sets how many messages in a JSON-RPC batch
+// are dispatched to the wrapped MCPServer concurrently. Defaults to 10.
+// Non-positive values are ignored, since processBatch's worker semaphore
+// requires at least one slot to make progress.
+func WithStatelessHTTPBatchWorkers(n int) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		if n <= 0 {
+			return
+		}
+		s.batchWorkers = n
+	}
+}
+
+// WithStatelessHTTPEventStore sets the EventStore used to support resumable
+// SSE streams via the Last-Event-ID header. Defaults to an in-memory store
+// that does not survive a process restart.
+func WithStatelessHTTPEventStore(store EventStore) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.eventStore = store
+	}
+}
+
+// WithStatelessHTTPHeartbeatInterval sets how often a heartbeat comment is
+// written to open SSE streams, to keep intermediate proxies from treating the
+// connection as idle and closing it. A value of 0 disables heartbeats.
+func WithStatelessHTTPHeartbeatInterval(d time.Duration) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.heartbeatInterval = d
+	}
+}
+
+// WithStatelessHTTPMiddleware wraps every request in the given
+// http.Handler middleware, outermost first, before it reaches the base path
+// router. Middleware runs ahead of JWT authentication, so it can be used to
+// implement other auth schemes or cross-cutting concerns like rate limiting.
+func WithStatelessHTTPMiddleware(mw ...func(http.Handler) http.Handler) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.middlewares = append(s.middlewares, mw...)
+	}
+}
+
+// WithStatelessHTTPJWTSecret requires every request to carry a valid HS256
+// bearer JWT in the Authorization header, signed with secret. The token's
+// "iat" claim must be within ±60s of the server's clock.
+func WithStatelessHTTPJWTSecret(secret []byte) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.jwtSecret = secret
+	}
+}
+
+// WithStatelessHTTPCORS enables CORS responses for the given origins. Use
+// "*" to allow any origin.
+func WithStatelessHTTPCORS(origins []string) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.corsOrigins = origins
+	}
+}
+
+// WithStatelessHTTPAllowedHosts restricts requests to the given Host header
+// values (vhosts), rejecting anything else with 403 Forbidden. Use "*" to
+// allow any host.
+func WithStatelessHTTPAllowedHosts(vhosts []string) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.allowedHosts = vhosts
+	}
+}
+
+// WithStatelessHTTPTracer sets a Tracer that receives one RequestTrace per
+// JSON-RPC message processed (or per batch entry), for observability
+// integrations that would otherwise require forking the handler.
+func WithStatelessHTTPTracer(tr Tracer) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.tracer = tr
+	}
+}
+
+// WithStatelessHTTPCompression turns gzip/deflate response compression on or
+// off. Compression is enabled by default.
+func WithStatelessHTTPCompression(enabled bool) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.compressionEnabled = enabled
+	}
+}
+
+// WithStatelessHTTPCompressionThreshold sets the minimum response size, in
+// bytes, that will be compressed. Responses smaller than this are written
+// uncompressed to avoid wasted CPU on small notifications. Defaults to 1KiB.
+func WithStatelessHTTPCompressionThreshold(bytes int) StatelessHTTPOption {
+	return func(s *StatelessHTTPServer) {
+		s.compressionThreshold = bytes
+	}
+}
+
 // NewStatelessHTTPServer creates a new StatelessHTTP server wrapper around an MCPServer.
 // It initializes the server with a default error logger that discards all output.
 func NewStatelessHTTPServer(server *MCPServer, opts ...StatelessHTTPOption) *StatelessHTTPServer {
@@ -61,12 +461,23 @@ func NewStatelessHTTPServer(server *MCPServer, opts ...StatelessHTTPOption) *Sta
 			"",
 			log.LstdFlags,
 		), // Default to discarding logs
+		batchWorkers:         defaultBatchWorkers,
+		eventStore:           newMemoryEventStore(),
+		heartbeatInterval:    defaultHeartbeatInterval,
+		compressionEnabled:   true,
+		compressionThreshold: defaultCompressionThreshold,
 	}
 
 	for _, opt := range opts {
 		opt(svr)
 	}
 
+	var handler http.Handler = http.HandlerFunc(svr.authGate)
+	for i := len(svr.middlewares) - 1; i >= 0; i-- {
+		handler = svr.middlewares[i](handler)
+	}
+	svr.handler = handler
+
 	return svr
 }
 
@@ -76,13 +487,21 @@ func (s *StatelessHTTPServer) SetErrorLogger(logger *log.Logger) {
 	s.errLogger = logger
 }
 
-// SetContextFunc sets a function that will be called to customise the context
-// to the server. Note that the StatelessHTTP server uses the same context for all requests,
-// so this function will only be called once per server instance.
+// SetContextFunc sets a function that is called once per request, with that
+// request, to customise the context passed to the wrapped MCPServer.
 func (s *StatelessHTTPServer) SetContextFunc(fn StatelessHTTPContextFunc) {
 	s.contextFunc = fn
 }
 
+// SetContextFuncLegacy sets a StatelessHTTPContextFuncLegacy.
+//
+// Deprecated: use SetContextFunc instead.
+func (s *StatelessHTTPServer) SetContextFuncLegacy(fn StatelessHTTPContextFuncLegacy) {
+	s.contextFunc = func(ctx context.Context, _ *http.Request) context.Context {
+		return fn(ctx)
+	}
+}
+
 // Start begins serving SSE connections on the specified address.
 // It sets up HTTP handlers for SSE and message endpoints.
 func (s *StatelessHTTPServer) Start(addr string) error {
@@ -123,6 +542,20 @@ func (s *StatelessHTTPServer) processMessage(
 		return
 	}
 
+	if r.Method == http.MethodGet {
+		// A GET request opens a long-lived SSE stream for server-initiated
+		// notifications; it carries no body, so the Content-Type check below
+		// does not apply.
+		s.handleSSE(w, r)
+		return
+	}
+
+	// Check if the request is a POST
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	// Check if Content-Type header is application/json
 	contentType := r.Header.Get("Content-Type")
 	if contentType != "application/json" {
@@ -130,47 +563,364 @@ func (s *StatelessHTTPServer) processMessage(
 		return
 	}
 
-	if r.Method == http.MethodGet {
-		// Return 405 as we don't support Streaming Yet
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
+	defer r.Body.Close()
+
+	ctx := r.Context()
+	if s.contextFunc != nil {
+		ctx = s.contextFunc(ctx, r)
 	}
 
-	// Check if the request is a POST
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	// If this POST names the Mcp-Session-Id of a currently open SSE stream,
+	// attach that stream's ClientSession to ctx so that server-initiated
+	// notifications emitted while handling this message (progress updates,
+	// logging messages, resource-change events) are routed to it instead of
+	// being dropped.
+	if sessionID := r.Header.Get(mcpSessionIDHeader); sessionID != "" {
+		if v, ok := s.sseSessions.Load(sessionID); ok {
+			ctx = s.server.WithContext(ctx, v.(*statelessSSESession))
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeJSONRPCError(w, nil, mcp.PARSE_ERROR, "Parse error")
 		return
 	}
 
-	defer r.Body.Close()
+	// Peek at the first non-whitespace byte to decide between a single
+	// message and a JSON-RPC batch (an array of messages).
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		s.processBatch(w, r, ctx, trimmed)
+		return
+	}
 
 	// Parse message as raw JSON
 	var rawMessage json.RawMessage
-	if err := json.NewDecoder(r.Body).Decode(&rawMessage); err != nil {
+	if err := json.Unmarshal(trimmed, &rawMessage); err != nil {
 		s.writeJSONRPCError(w, nil, mcp.PARSE_ERROR, "Parse error")
 		return
 	}
 	// Handle the message using the wrapped server
-	response := s.server.HandleMessage(r.Context(), rawMessage)
+	start := time.Now()
+	response := s.server.HandleMessage(ctx, rawMessage)
+	duration := time.Since(start)
 
 	if response != nil {
-		// send http response
-		w.Header().Set("content-type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		err := json.NewEncoder(w).Encode(response)
+		respBytes, err := json.Marshal(response)
 		if err != nil {
-			s.errLogger.Printf("Error writing response: %v", err)
+			s.errLogger.Printf("Error marshaling response: %v", err)
+			s.traceMessage(r, trimmed, nil, http.StatusInternalServerError, duration, err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
+
+		// send http response
+		w.Header().Set("content-type", "application/json")
+		s.writeCompressed(w, r, http.StatusOK, respBytes)
+		s.traceMessage(r, trimmed, respBytes, http.StatusOK, duration, nil)
 	} else {
 		// for notifications, just send 202 accepted with no body
 		w.WriteHeader(http.StatusAccepted)
+		s.traceMessage(r, trimmed, nil, http.StatusAccepted, duration, nil)
+	}
+}
+
+// traceMessage reports a processed JSON-RPC message to the configured
+// Tracer, if any. It is a no-op when no tracer is configured.
+func (s *StatelessHTTPServer) traceMessage(
+	r *http.Request,
+	rawRequest json.RawMessage,
+	rawResponse json.RawMessage,
+	statusCode int,
+	duration time.Duration,
+	err error,
+) {
+	if s.tracer == nil {
+		return
+	}
+
+	var envelope jsonrpcEnvelope
+	_ = json.Unmarshal(rawRequest, &envelope)
+
+	s.tracer.TraceRequest(RequestTrace{
+		Method:     envelope.Method,
+		ID:         envelope.ID,
+		Request:    rawRequest,
+		Response:   rawResponse,
+		StatusCode: statusCode,
+		Duration:   duration,
+		RemoteAddr: r.RemoteAddr,
+		Err:        err,
+	})
+}
+
+// writeCompressed writes statusCode and data as the HTTP response, gzip- or
+// deflate-compressing data when the client advertises support for it via
+// Accept-Encoding and data is at least s.compressionThreshold bytes.
+func (s *StatelessHTTPServer) writeCompressed(
+	w http.ResponseWriter,
+	r *http.Request,
+	statusCode int,
+	data []byte,
+) {
+	if s.compressionEnabled && len(data) >= s.compressionThreshold {
+		switch {
+		case acceptsEncoding(r, "gzip"):
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(statusCode)
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			if _, err := gz.Write(data); err != nil {
+				s.errLogger.Printf("Error writing gzip response: %v", err)
+			}
+			return
+		case acceptsEncoding(r, "deflate"):
+			w.Header().Set("Content-Encoding", "deflate")
+			w.WriteHeader(statusCode)
+			fl := zlib.NewWriter(w)
+			defer fl.Close()
+			if _, err := fl.Write(data); err != nil {
+				s.errLogger.Printf("Error writing deflate response: %v", err)
+			}
+			return
+		}
+	}
+
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
+
+// acceptsEncoding reports whether r's Accept-Encoding header lists encoding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.EqualFold(strings.TrimSpace(enc), encoding) {
+			return true
+		}
+	}
+	return false
+}
+
+// processBatch handles a JSON-RPC batch request: an array of messages that
+// are dispatched to the wrapped MCPServer concurrently (bounded by
+// s.batchWorkers), collecting the non-nil responses into a single JSON array
+// response, per the JSON-RPC 2.0 batch spec.
+func (s *StatelessHTTPServer) processBatch(
+	w http.ResponseWriter,
+	r *http.Request,
+	ctx context.Context,
+	body []byte,
+) {
+	var messages []json.RawMessage
+	if err := json.Unmarshal(body, &messages); err != nil {
+		s.writeJSONRPCError(w, nil, mcp.PARSE_ERROR, "Parse error")
+		return
+	}
+
+	if len(messages) == 0 {
+		s.writeJSONRPCError(w, nil, mcp.INVALID_REQUEST, "Invalid Request: empty batch")
+		return
+	}
+
+	if s.batchLimit > 0 && len(messages) > s.batchLimit {
+		s.writeJSONRPCError(
+			w, nil, mcp.INVALID_REQUEST,
+			fmt.Sprintf("Invalid Request: batch size %d exceeds limit of %d", len(messages), s.batchLimit),
+		)
+		return
+	}
+
+	responses := make([]any, len(messages))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.batchWorkers)
+	for i, msg := range messages {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, msg json.RawMessage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			start := time.Now()
+			resp := s.server.HandleMessage(ctx, msg)
+			duration := time.Since(start)
+			responses[i] = resp
+
+			if resp == nil {
+				s.traceMessage(r, msg, nil, http.StatusAccepted, duration, nil)
+				return
+			}
+			respBytes, err := json.Marshal(resp)
+			if err != nil {
+				s.traceMessage(r, msg, nil, http.StatusInternalServerError, duration, err)
+				return
+			}
+			s.traceMessage(r, msg, respBytes, http.StatusOK, duration, nil)
+		}(i, msg)
+	}
+	wg.Wait()
+
+	results := make([]any, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			results = append(results, resp)
+		}
+	}
+
+	if len(results) == 0 {
+		// All entries were notifications: no body, just acknowledge.
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	resultBytes, err := json.Marshal(results)
+	if err != nil {
+		s.errLogger.Printf("Error marshaling batch response: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", "application/json")
+	s.writeCompressed(w, r, http.StatusOK, resultBytes)
+}
+
+// handleSSE implements the GET side of the streamable-HTTP transport: it
+// upgrades the response to text/event-stream, registers a ClientSession with
+// the wrapped MCPServer so server-initiated notifications addressed to this
+// connection are delivered, and streams them as SSE events until the client
+// disconnects. A Last-Event-ID header triggers a replay of missed events via
+// the configured EventStore before new events are streamed.
+func (s *StatelessHTTPServer) handleSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	if s.contextFunc != nil {
+		ctx = s.contextFunc(ctx, r)
+	}
+
+	// A reconnecting client echoes the Mcp-Session-Id it was given on a
+	// prior connection so that Last-Event-ID replay and any notifications
+	// addressed to it resume the same logical stream, rather than starting
+	// a new one that has no history in s.eventStore.
+	sessionID := r.Header.Get(mcpSessionIDHeader)
+	if sessionID == "" {
+		sessionID = generateSessionID()
+	}
+	session := newStatelessSSESession(sessionID)
+	if err := s.server.RegisterSession(ctx, session); err != nil {
+		s.errLogger.Printf("Error registering SSE session: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.sseSessions.Store(sessionID, session)
+	defer s.sseSessions.Delete(sessionID)
+	defer s.server.UnregisterSession(ctx, sessionID)
+
+	w.Header().Set(mcpSessionIDHeader, sessionID)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		err := s.eventStore.Replay(sessionID, lastEventID, func(id string, data []byte) error {
+			return writeSSEEvent(w, flusher, id, data)
+		})
+		if err != nil {
+			s.errLogger.Printf("Error replaying SSE events: %v", err)
+			return
+		}
+	}
+
+	var heartbeat <-chan time.Time
+	if s.heartbeatInterval > 0 {
+		ticker := time.NewTicker(s.heartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case notification, ok := <-session.notifications:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(notification)
+			if err != nil {
+				s.errLogger.Printf("Error marshaling SSE notification: %v", err)
+				continue
+			}
+			id, err := s.eventStore.Append(sessionID, data)
+			if err != nil {
+				s.errLogger.Printf("Error storing SSE event: %v", err)
+				continue
+			}
+			if err := writeSSEEvent(w, flusher, id, data); err != nil {
+				return
+			}
+		}
 	}
 }
 
-// ServeHTTP implements the http.Handler interface.
+// writeSSEEvent writes a single SSE "data:" frame with an "id:" field and
+// flushes it to the client.
+func writeSSEEvent(w io.Writer, flusher http.Flusher, id string, data []byte) error {
+	if _, err := fmt.Fprintf(w, "id: %s\ndata: %s\n\n", id, data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// ServeHTTP implements the http.Handler interface. It enforces the vhost and
+// CORS options before dispatching to the user-supplied middleware chain,
+// which wraps the built-in JWT authentication gate and, ultimately, the base
+// path router.
 func (s *StatelessHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.hostAllowed(r) {
+		http.Error(w, "Forbidden: host not allowed", http.StatusForbidden)
+		return
+	}
+
+	s.applyCORS(w, r)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.handler.ServeHTTP(w, r)
+}
+
+// authGate enforces the JWT option, if configured, before handing off to
+// route. It is the innermost handler wrapped by any configured middleware,
+// so middleware set via WithStatelessHTTPMiddleware runs ahead of it and can
+// implement other auth schemes or reject a request before the built-in JWT
+// check ever runs.
+func (s *StatelessHTTPServer) authGate(w http.ResponseWriter, r *http.Request) {
+	if len(s.jwtSecret) > 0 {
+		if err := s.authenticate(r); err != nil {
+			s.writeUnauthorized(w, fmt.Sprintf("Unauthorized: %v", err))
+			return
+		}
+	}
+
+	s.route(w, r)
+}
+
+// route implements the base path routing previously done directly in
+// ServeHTTP; it is wrapped by authGate.
+func (s *StatelessHTTPServer) route(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	if path == s.basePath {
 		s.processMessage(w, r)
@@ -179,3 +929,120 @@ func (s *StatelessHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request)
 
 	http.NotFound(w, r)
 }
+
+// hostAllowed reports whether r.Host is permitted by WithStatelessHTTPAllowedHosts.
+// With no allowed hosts configured, every host is permitted.
+func (s *StatelessHTTPServer) hostAllowed(r *http.Request) bool {
+	if len(s.allowedHosts) == 0 {
+		return true
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, allowed := range s.allowedHosts {
+		if allowed == "*" || allowed == host {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCORS sets CORS response headers when the request's Origin header
+// matches one of the origins configured via WithStatelessHTTPCORS.
+func (s *StatelessHTTPServer) applyCORS(w http.ResponseWriter, r *http.Request) {
+	if len(s.corsOrigins) == 0 {
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+
+	for _, allowed := range s.corsOrigins {
+		if allowed == "*" || allowed == origin {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID")
+			w.Header().Set("Vary", "Origin")
+			return
+		}
+	}
+}
+
+// authenticate validates the bearer JWT on r against s.jwtSecret.
+func (s *StatelessHTTPServer) authenticate(r *http.Request) error {
+	const prefix = "Bearer "
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, prefix) {
+		return fmt.Errorf("missing bearer token")
+	}
+
+	return verifyStatelessJWT(strings.TrimPrefix(authHeader, prefix), s.jwtSecret)
+}
+
+// writeUnauthorized writes a JSON-RPC error response with a 401 status and a
+// WWW-Authenticate header.
+func (s *StatelessHTTPServer) writeUnauthorized(w http.ResponseWriter, message string) {
+	response := createErrorResponse(nil, mcp.INVALID_REQUEST, message)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(response)
+}
+
+// verifyStatelessJWT validates an HS256-signed JWT's signature and checks
+// that its "iat" claim is within maxJWTClockSkew of the current time.
+func verifyStatelessJWT(tokenString string, secret []byte) error {
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed token")
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("malformed header: %w", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return fmt.Errorf("malformed header: %w", err)
+	}
+	if header.Alg != "HS256" {
+		return fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed payload: %w", err)
+	}
+	var claims struct {
+		IssuedAt int64 `json:"iat"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("malformed claims: %w", err)
+	}
+
+	iat := time.Unix(claims.IssuedAt, 0)
+	skew := time.Since(iat)
+	if skew > maxJWTClockSkew || skew < -maxJWTClockSkew {
+		return fmt.Errorf("token is not fresh")
+	}
+
+	return nil
+}
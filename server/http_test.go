@@ -1,6 +1,20 @@
 package server
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestStatelessHTTP(t *testing.T) {
 	t.Run("Can instantiate", func(t *testing.T) {
@@ -18,4 +32,478 @@ func TestStatelessHTTP(t *testing.T) {
 			t.Errorf("Expected httpServer.basePath to be '/', got '%s'", httpServer.basePath)
 		}
 	})
+
+	t.Run("Can set batch limit", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithStatelessHTTPBatchLimit(5))
+
+		if httpServer.batchLimit != 5 {
+			t.Errorf("Expected httpServer.batchLimit to be 5, got %d", httpServer.batchLimit)
+		}
+	})
+
+	t.Run("Defaults to defaultBatchWorkers and can be overridden", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer)
+
+		if httpServer.batchWorkers != defaultBatchWorkers {
+			t.Errorf("Expected httpServer.batchWorkers to default to %d, got %d", defaultBatchWorkers, httpServer.batchWorkers)
+		}
+
+		httpServer = NewStatelessHTTPServer(mcpServer, WithStatelessHTTPBatchWorkers(3))
+		if httpServer.batchWorkers != 3 {
+			t.Errorf("Expected httpServer.batchWorkers to be 3, got %d", httpServer.batchWorkers)
+		}
+	})
+
+	t.Run("Ignores non-positive batch worker counts", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithStatelessHTTPBatchWorkers(0))
+
+		if httpServer.batchWorkers != defaultBatchWorkers {
+			t.Errorf("Expected a non-positive worker count to be ignored, got %d", httpServer.batchWorkers)
+		}
+	})
+
+	t.Run("Can set auth and vhost options", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(
+			mcpServer,
+			WithStatelessHTTPJWTSecret([]byte("secret")),
+			WithStatelessHTTPCORS([]string{"https://example.com"}),
+			WithStatelessHTTPAllowedHosts([]string{"example.com"}),
+		)
+
+		if string(httpServer.jwtSecret) != "secret" {
+			t.Errorf("Expected httpServer.jwtSecret to be 'secret', got '%s'", httpServer.jwtSecret)
+		}
+		if !httpServer.hostAllowed(&http.Request{Host: "example.com"}) {
+			t.Error("Expected example.com to be an allowed host")
+		}
+		if httpServer.hostAllowed(&http.Request{Host: "evil.com"}) {
+			t.Error("Expected evil.com to not be an allowed host")
+		}
+	})
+
+	t.Run("Context func receives the request", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		type tenantKey struct{}
+
+		var gotRequest *http.Request
+		httpServer := NewStatelessHTTPServer(
+			mcpServer,
+			WithStatelessHTTPContextFunc(func(ctx context.Context, r *http.Request) context.Context {
+				gotRequest = r
+				return context.WithValue(ctx, tenantKey{}, r.Header.Get("X-Tenant-Id"))
+			}),
+		)
+
+		req := &http.Request{Header: http.Header{"X-Tenant-Id": []string{"acme"}}}
+		ctx := httpServer.contextFunc(context.Background(), req)
+
+		if gotRequest != req {
+			t.Error("Expected context func to receive the request")
+		}
+		if tenant, _ := ctx.Value(tenantKey{}).(string); tenant != "acme" {
+			t.Errorf("Expected tenant 'acme', got '%s'", tenant)
+		}
+	})
+}
+
+// signTestJWT builds an HS256 JWT with the given "iat" claim, signed with
+// secret, in the form verifyStatelessJWT expects.
+func signTestJWT(secret []byte, iat time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d}`, iat.Unix())))
+	signingInput := header + "." + payload
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return signingInput + "." + sig
+}
+
+func TestStatelessHTTPAuthenticate(t *testing.T) {
+	secret := []byte("secret")
+
+	t.Run("a validly-signed, fresh token is accepted", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"), WithStatelessHTTPJWTSecret(secret))
+
+		req := newJSONRPCPostRequest(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+		req.Header.Set("Authorization", "Bearer "+signTestJWT(secret, time.Now()))
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("a token signed with the wrong secret is rejected", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"), WithStatelessHTTPJWTSecret(secret))
+
+		req := newJSONRPCPostRequest(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+		req.Header.Set("Authorization", "Bearer "+signTestJWT([]byte("wrong-secret"), time.Now()))
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("a stale token is rejected", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"), WithStatelessHTTPJWTSecret(secret))
+
+		req := newJSONRPCPostRequest(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+		req.Header.Set("Authorization", "Bearer "+signTestJWT(secret, time.Now().Add(-time.Hour)))
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("a token with an unsupported alg is rejected", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"), WithStatelessHTTPJWTSecret(secret))
+
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+		payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d}`, time.Now().Unix())))
+		token := header + "." + payload + "."
+
+		req := newJSONRPCPostRequest(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("a missing Authorization header is rejected", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"), WithStatelessHTTPJWTSecret(secret))
+
+		req := newJSONRPCPostRequest(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+func TestStatelessHTTPMiddlewareOrdering(t *testing.T) {
+	t.Run("Middleware runs ahead of the JWT gate", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		var middlewareRan bool
+		httpServer := NewStatelessHTTPServer(
+			mcpServer,
+			WithHTTPBasePath("/"),
+			WithStatelessHTTPJWTSecret([]byte("secret")),
+			WithStatelessHTTPMiddleware(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					middlewareRan = true
+					w.WriteHeader(http.StatusTeapot)
+				})
+			}),
+		)
+
+		req := newJSONRPCPostRequest(`{"jsonrpc":"2.0","method":"ping","id":1}`)
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if !middlewareRan {
+			t.Error("Expected middleware to run even though no Authorization header was set")
+		}
+		if rec.Code != http.StatusTeapot {
+			t.Errorf("Expected middleware's response to win over the JWT gate, got status %d", rec.Code)
+		}
+	})
+}
+
+// newJSONRPCPostRequest builds a POST request carrying body as its JSON-RPC
+// payload, with the Accept and Content-Type headers processMessage requires.
+func newJSONRPCPostRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestStatelessHTTPBatch(t *testing.T) {
+	t.Run("dispatches a batch and returns one response per request", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"))
+
+		body := `[{"jsonrpc":"2.0","method":"ping","id":1},{"jsonrpc":"2.0","method":"ping","id":2}]`
+		req := newJSONRPCPostRequest(body)
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var responses []json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &responses); err != nil {
+			t.Fatalf("Expected a JSON array response, got %q: %v", rec.Body.String(), err)
+		}
+		if len(responses) != 2 {
+			t.Errorf("Expected 2 responses, got %d", len(responses))
+		}
+	})
+
+	t.Run("a batch of only notifications is accepted with no body", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"))
+
+		body := `[{"jsonrpc":"2.0","method":"notifications/progress"},{"jsonrpc":"2.0","method":"notifications/progress"}]`
+		req := newJSONRPCPostRequest(body)
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("Expected status 202, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("a batch over the configured limit is rejected", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"), WithStatelessHTTPBatchLimit(1))
+
+		body := `[{"jsonrpc":"2.0","method":"ping","id":1},{"jsonrpc":"2.0","method":"ping","id":2}]`
+		req := newJSONRPCPostRequest(body)
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("Expected a JSON-RPC error response (status 400), got %d", rec.Code)
+		}
+		var envelope struct {
+			Error *struct {
+				Code int `json:"code"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("Expected a JSON-RPC error response, got %q: %v", rec.Body.String(), err)
+		}
+		if envelope.Error == nil {
+			t.Fatalf("Expected an error response for an oversized batch, got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("batch dispatch honours a configured worker pool size", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"), WithStatelessHTTPBatchWorkers(1))
+
+		if httpServer.batchWorkers != 1 {
+			t.Errorf("Expected httpServer.batchWorkers to be 1, got %d", httpServer.batchWorkers)
+		}
+
+		body := bytes.Repeat([]byte(`{"jsonrpc":"2.0","method":"ping","id":1},`), 3)
+		batch := "[" + strings.TrimSuffix(string(body), ",") + "]"
+		req := newJSONRPCPostRequest(batch)
+		rec := httptest.NewRecorder()
+		httpServer.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+}
+
+type recordingTracer struct {
+	traces []RequestTrace
+}
+
+func (r *recordingTracer) TraceRequest(info RequestTrace) {
+	r.traces = append(r.traces, info)
+}
+
+func TestStatelessHTTPTracer(t *testing.T) {
+	t.Run("traceMessage invokes the configured tracer", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		tracer := &recordingTracer{}
+		httpServer := NewStatelessHTTPServer(mcpServer, WithStatelessHTTPTracer(tracer))
+
+		req := &http.Request{RemoteAddr: "127.0.0.1:1234"}
+		httpServer.traceMessage(req, []byte(`{"jsonrpc":"2.0","method":"ping","id":1}`), []byte(`{"jsonrpc":"2.0","result":{},"id":1}`), http.StatusOK, time.Millisecond, nil)
+
+		if len(tracer.traces) != 1 {
+			t.Fatalf("Expected 1 trace, got %d", len(tracer.traces))
+		}
+		got := tracer.traces[0]
+		if got.Method != "ping" {
+			t.Errorf("Expected method 'ping', got '%s'", got.Method)
+		}
+		if got.RemoteAddr != "127.0.0.1:1234" {
+			t.Errorf("Expected remote addr '127.0.0.1:1234', got '%s'", got.RemoteAddr)
+		}
+	})
+
+	t.Run("NewLogTracer does not panic", func(t *testing.T) {
+		tracer := NewLogTracer(log.New(log.Writer(), "", 0))
+		tracer.TraceRequest(RequestTrace{Method: "ping", StatusCode: http.StatusOK})
+	})
+}
+
+func TestStatelessHTTPCompression(t *testing.T) {
+	t.Run("Defaults to enabled with a 1KiB threshold", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer)
+
+		if !httpServer.compressionEnabled {
+			t.Error("Expected compression to be enabled by default")
+		}
+		if httpServer.compressionThreshold != defaultCompressionThreshold {
+			t.Errorf("Expected default compression threshold %d, got %d", defaultCompressionThreshold, httpServer.compressionThreshold)
+		}
+	})
+
+	t.Run("Can be disabled and have its threshold overridden", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(
+			mcpServer,
+			WithStatelessHTTPCompression(false),
+			WithStatelessHTTPCompressionThreshold(64),
+		)
+
+		if httpServer.compressionEnabled {
+			t.Error("Expected compression to be disabled")
+		}
+		if httpServer.compressionThreshold != 64 {
+			t.Errorf("Expected compression threshold 64, got %d", httpServer.compressionThreshold)
+		}
+	})
+
+	t.Run("acceptsEncoding matches a comma-separated Accept-Encoding header", func(t *testing.T) {
+		req := &http.Request{Header: http.Header{"Accept-Encoding": []string{"br, gzip, deflate"}}}
+
+		if !acceptsEncoding(req, "gzip") {
+			t.Error("Expected gzip to be accepted")
+		}
+		if acceptsEncoding(req, "identity") {
+			t.Error("Expected identity to not be accepted")
+		}
+	})
+}
+
+func TestStatelessHTTPSSE(t *testing.T) {
+	t.Run("streams text/event-stream and assigns an Mcp-Session-Id", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		req.Header.Set("Accept", "text/event-stream")
+		rec := httptest.NewRecorder()
+
+		done := make(chan struct{})
+		go func() {
+			httpServer.ServeHTTP(rec, req)
+			close(done)
+		}()
+		cancel()
+		<-done
+
+		if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("Expected Content-Type text/event-stream, got %q", ct)
+		}
+		if rec.Header().Get("Mcp-Session-Id") == "" {
+			t.Error("Expected a non-empty Mcp-Session-Id response header")
+		}
+	})
+
+	t.Run("reconnecting with Mcp-Session-Id resumes the same stream", func(t *testing.T) {
+		mcpServer := NewMCPServer("test", "1.0.0")
+		httpServer := NewStatelessHTTPServer(mcpServer, WithHTTPBasePath("/"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		req.Header.Set("Accept", "text/event-stream")
+		rec := httptest.NewRecorder()
+
+		done := make(chan struct{})
+		go func() {
+			httpServer.ServeHTTP(rec, req)
+			close(done)
+		}()
+		cancel()
+		<-done
+
+		sessionID := rec.Header().Get("Mcp-Session-Id")
+		if sessionID == "" {
+			t.Fatal("Expected a non-empty Mcp-Session-Id response header")
+		}
+
+		ctx2, cancel2 := context.WithCancel(context.Background())
+		req2 := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx2)
+		req2.Header.Set("Accept", "text/event-stream")
+		req2.Header.Set("Mcp-Session-Id", sessionID)
+		rec2 := httptest.NewRecorder()
+
+		done2 := make(chan struct{})
+		go func() {
+			httpServer.ServeHTTP(rec2, req2)
+			close(done2)
+		}()
+		cancel2()
+		<-done2
+
+		if got := rec2.Header().Get("Mcp-Session-Id"); got != sessionID {
+			t.Errorf("Expected reconnect to resume session %q, got %q", sessionID, got)
+		}
+	})
+}
+
+func TestMemoryEventStore(t *testing.T) {
+	t.Run("Replay is a no-op without a Last-Event-ID", func(t *testing.T) {
+		store := newMemoryEventStore()
+		id, err := store.Append("stream-1", []byte("hello"))
+		if err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+
+		var replayed []string
+		err = store.Replay("stream-1", "", func(id string, data []byte) error {
+			replayed = append(replayed, id)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Replay returned error: %v", err)
+		}
+		if len(replayed) != 0 {
+			t.Errorf("Expected no events replayed, got %v (first event id %s)", replayed, id)
+		}
+	})
+
+	t.Run("Replay resumes after the given event", func(t *testing.T) {
+		store := newMemoryEventStore()
+		first, _ := store.Append("stream-1", []byte("one"))
+		_, _ = store.Append("stream-1", []byte("two"))
+		_, _ = store.Append("stream-1", []byte("three"))
+
+		var replayed [][]byte
+		err := store.Replay("stream-1", first, func(id string, data []byte) error {
+			replayed = append(replayed, data)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Replay returned error: %v", err)
+		}
+		if len(replayed) != 2 {
+			t.Fatalf("Expected 2 events replayed, got %d", len(replayed))
+		}
+		if string(replayed[0]) != "two" || string(replayed[1]) != "three" {
+			t.Errorf("Unexpected replay order: %v", replayed)
+		}
+	})
 }